@@ -0,0 +1,61 @@
+package systemd
+
+import (
+	"math"
+	"strings"
+
+	. "github.com/wavefronthq/wavefront-kubernetes-collector/internal/metrics"
+
+	"github.com/golang/glog"
+)
+
+// collectUnitCGroupMetrics reads cgroup resource accounting properties off each .service and
+// .slice unit, complementing kubelet/cAdvisor container metrics with systemd-level attribution
+// for non-container host workloads (kubelet itself, containerd, sshd, DaemonSet host services).
+// units should come from filterUnitsForCGroup rather than filterUnits: the default unitExclude
+// hides .slice units, which is exactly what this collector reports on.
+func (src *systemdMetricsSource) collectUnitCGroupMetrics(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
+	for _, unit := range units {
+		var unitType string
+		switch {
+		case strings.HasSuffix(unit.Name, ".service"):
+			unitType = "Service"
+		case strings.HasSuffix(unit.Name, ".slice"):
+			unitType = "Slice"
+		default:
+			continue
+		}
+
+		props, err := cache.typed(unit.Name, unitType)
+		if err != nil {
+			glog.V(4).Infof("couldn't get unit '%s' %s properties: %s", unit.Name, unitType, err)
+			continue
+		}
+
+		slice := unit.Name
+		if s, ok := props["Slice"].(string); ok && s != "" {
+			slice = s
+		}
+
+		tags := map[string]string{}
+		setTag(tags, "name", unit.Name)
+		setTag(tags, "slice", slice)
+
+		// These return MaxUint64 when unset, same as TasksCurrent elsewhere in this package.
+		if val, ok := props["MemoryCurrent"].(uint64); ok && val != math.MaxUint64 {
+			ch <- src.metricPoint("unit_memory_bytes", float64(val), now, tags)
+		}
+		if val, ok := props["CPUUsageNSec"].(uint64); ok && val != math.MaxUint64 {
+			ch <- src.metricPoint("unit_cpu_usage_ns", float64(val), now, tags)
+		}
+		if val, ok := props["IOReadBytes"].(uint64); ok && val != math.MaxUint64 {
+			ch <- src.metricPoint("unit_io_read_bytes", float64(val), now, tags)
+		}
+		if val, ok := props["IOWriteBytes"].(uint64); ok && val != math.MaxUint64 {
+			ch <- src.metricPoint("unit_io_write_bytes", float64(val), now, tags)
+		}
+		if val, ok := props["TasksCurrent"].(uint64); ok && val != math.MaxUint64 {
+			ch <- src.metricPoint("unit_cgroup_tasks_current", float64(val), now, tags)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package systemd
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/wavefronthq/wavefront-kubernetes-collector/internal/metrics"
+
+	"github.com/coreos/go-systemd/dbus"
+	"github.com/golang/glog"
+)
+
+// unitChangePollInterval is how often conn.SubscribeUnits lists units over dbus and diffs them
+// against the previous listing. Changes are found only by this polling, so a unit that leaves
+// and returns to the same ActiveState within one interval produces no transition.
+const unitChangePollInterval = 2 * time.Second
+
+// unitEventWatcher bridges systemd unit state changes into the pull-style gather channel used
+// by ScrapeMetrics: a background goroutine receives the changed units from conn.SubscribeUnits
+// and buffers transition points, which are drained into the next scrape. This gives
+// visibility into flapping units between scrapes without shortening the scrape interval.
+type unitEventWatcher struct {
+	src *systemdMetricsSource
+
+	mu        sync.Mutex
+	seeded    bool
+	lastState map[string]string
+	buffered  []*MetricPoint
+}
+
+func newUnitEventWatcher(src *systemdMetricsSource) *unitEventWatcher {
+	return &unitEventWatcher{
+		src:       src,
+		lastState: make(map[string]string),
+	}
+}
+
+// start subscribes to systemd unit changes and begins buffering transitions. It returns
+// immediately; changes are picked up by the next call to drain.
+func (w *unitEventWatcher) start() error {
+	conn, err := dbus.New()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Subscribe(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	changes, errs := conn.SubscribeUnits(unitChangePollInterval)
+
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case units, more := <-changes:
+				if !more {
+					return
+				}
+				w.handleChanges(units)
+			case err, more := <-errs:
+				if !more {
+					return
+				}
+				glog.Errorf("error watching systemd unit changes: %s", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (w *unitEventWatcher) handleChanges(units map[string]*dbus.UnitStatus) {
+	now := time.Now().Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// go-systemd diffs the first SubscribeUnits tick against an empty cache, so every
+	// currently-loaded unit comes through as a "change". Seed lastState from it without
+	// emitting; otherwise every collector start/restart produces a burst of spurious
+	// transitions with an empty from_state.
+	firstTick := !w.seeded
+	w.seeded = true
+
+	for name, status := range units {
+		toState := ""
+		if status != nil {
+			toState = status.ActiveState
+		}
+
+		fromState := w.lastState[name]
+		if toState == "" {
+			delete(w.lastState, name)
+		} else {
+			w.lastState[name] = toState
+		}
+
+		if firstTick || fromState == toState {
+			continue
+		}
+
+		tags := map[string]string{}
+		setTag(tags, "name", name)
+		setTag(tags, "from_state", fromState)
+		setTag(tags, "to_state", toState)
+		w.buffered = append(w.buffered, w.src.metricPoint("unit_state_transition", 1, now, tags))
+	}
+}
+
+// drain returns and clears the transition points buffered since the last call.
+func (w *unitEventWatcher) drain() []*MetricPoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	points := w.buffered
+	w.buffered = nil
+	return points
+}
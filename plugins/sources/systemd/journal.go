@@ -0,0 +1,90 @@
+// +build linux,cgo
+
+package systemd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/wavefronthq/wavefront-kubernetes-collector/internal/metrics"
+
+	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/golang/glog"
+)
+
+// journalPriorities is indexed by the syslog PRIORITY field (0-7) as defined in sd-journal-fields(7).
+var journalPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// collectJournal counts journal entries seen in the last journalWindow, emitting a true
+// per-priority total (journal_messages_count) plus, when journalUnits matches an entry's
+// emitting unit, a separate per-priority-per-unit breakdown (journal_messages_by_unit_count).
+// The two are distinct metrics so that summing journal.messages.count by priority doesn't
+// double-count entries that also appear in the by-unit breakdown.
+func (src *systemdMetricsSource) collectJournal(ch chan<- *MetricPoint, now int64) {
+	journal, err := sdjournal.NewJournal()
+	if err != nil {
+		glog.Errorf("couldn't open systemd journal: %s", err)
+		return
+	}
+	defer journal.Close()
+
+	since := time.Unix(now, 0).Add(-src.journalWindow)
+	if err := journal.SeekRealtimeUsec(uint64(since.UnixNano() / 1000)); err != nil {
+		glog.Errorf("couldn't seek journal to %s: %s", since, err)
+		return
+	}
+
+	type unitKey struct {
+		priority string
+		unit     string
+	}
+	// totals is the true per-priority count across all entries. perUnit is additional detail
+	// for units matched by journalUnits; it's a breakdown, not a replacement, of totals.
+	totals := make(map[string]float64)
+	perUnit := make(map[unitKey]float64)
+
+	for {
+		n, err := journal.Next()
+		if err != nil {
+			glog.Errorf("error reading journal entry: %s", err)
+			break
+		}
+		if n == 0 {
+			break
+		}
+
+		entry, err := journal.GetEntry()
+		if err != nil {
+			glog.V(4).Infof("couldn't get journal entry: %s", err)
+			continue
+		}
+
+		priority := journalPriorityName(entry.Fields["PRIORITY"])
+		totals[priority]++
+
+		if unitName := entry.Fields["_SYSTEMD_UNIT"]; unitName != "" && src.journalUnits != nil && src.journalUnits.MatchString(unitName) {
+			perUnit[unitKey{priority: priority, unit: unitName}]++
+		}
+	}
+
+	for priority, count := range totals {
+		tags := map[string]string{}
+		setTag(tags, "priority", priority)
+		ch <- src.metricPoint("journal_messages_count", count, now, tags)
+	}
+	for key, count := range perUnit {
+		tags := map[string]string{}
+		setTag(tags, "priority", key.priority)
+		setTag(tags, "unit", key.unit)
+		ch <- src.metricPoint("journal_messages_by_unit_count", count, now, tags)
+	}
+}
+
+func journalPriorityName(raw string) string {
+	idx, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || idx < 0 || idx >= len(journalPriorities) {
+		return "unknown"
+	}
+	return journalPriorities[idx]
+}
@@ -0,0 +1,14 @@
+// +build !linux !cgo
+
+package systemd
+
+import (
+	. "github.com/wavefronthq/wavefront-kubernetes-collector/internal/metrics"
+
+	"github.com/golang/glog"
+)
+
+// collectJournal is a no-op on platforms without libsystemd/cgo support.
+func (src *systemdMetricsSource) collectJournal(ch chan<- *MetricPoint, now int64) {
+	glog.Warningf("journal metrics were requested but this binary was built without libsystemd support")
+}
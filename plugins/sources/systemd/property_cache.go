@@ -0,0 +1,62 @@
+package systemd
+
+import (
+	"sync"
+
+	"github.com/coreos/go-systemd/dbus"
+)
+
+// unitPropertyCache batches dbus property reads per unit. Collectors fetch an entire
+// interface's properties in one GetUnitProperties/GetUnitTypeProperties call and memoize the
+// result, so that when two collectors want overlapping properties for the same unit (e.g. both
+// collectUnitStatusMetrics and collectUnitTasksMetrics read the Service interface) a collector
+// that asks after the result is stored reuses it instead of making another dbus round trip.
+// Collectors that miss the same key at the same time each make the call.
+type unitPropertyCache struct {
+	conn *dbus.Conn
+
+	mu    sync.Mutex
+	cache map[string]map[string]interface{}
+}
+
+func newUnitPropertyCache(conn *dbus.Conn) *unitPropertyCache {
+	return &unitPropertyCache{
+		conn:  conn,
+		cache: make(map[string]map[string]interface{}),
+	}
+}
+
+// generic returns the unit's properties from the base org.freedesktop.systemd1.Unit interface
+// (ActiveState, LoadState, ActiveEnterTimestamp, ...).
+func (c *unitPropertyCache) generic(unitName string) (map[string]interface{}, error) {
+	return c.fetch(unitName, func() (map[string]interface{}, error) {
+		return c.conn.GetUnitProperties(unitName)
+	})
+}
+
+// typed returns the unit's properties from its type-specific interface, e.g. "Service",
+// "Socket", "Timer", or "Mount" (NRestarts, TasksCurrent, NAccepted, LastTriggerUSec, ...).
+func (c *unitPropertyCache) typed(unitName, unitType string) (map[string]interface{}, error) {
+	return c.fetch(unitName+":"+unitType, func() (map[string]interface{}, error) {
+		return c.conn.GetUnitTypeProperties(unitName, unitType)
+	})
+}
+
+func (c *unitPropertyCache) fetch(key string, load func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	c.mu.Lock()
+	if props, ok := c.cache[key]; ok {
+		c.mu.Unlock()
+		return props, nil
+	}
+	c.mu.Unlock()
+
+	props, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[key] = props
+	c.mu.Unlock()
+	return props, nil
+}
@@ -21,6 +21,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -48,8 +49,13 @@ type systemdMetricsSource struct {
 	collectTaskMetrics      bool
 	collectStartTimeMetrics bool
 	collectRestartMetrics   bool
+	collectCGroupMetrics    bool
+	collectJournalMetrics   bool
+	journalWindow           time.Duration
+	journalUnits            *regexp.Regexp
 	unitsFilter             *unitFilter
 	filters                 filter.Filter
+	eventWatcher            *unitEventWatcher
 }
 
 func (src *systemdMetricsSource) Name() string {
@@ -70,6 +76,7 @@ func (src *systemdMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch
 	}
 
 	now := time.Now().Unix()
+	scrapeStart := time.Now()
 	result := &DataBatch{
 		Timestamp: time.Now(),
 	}
@@ -94,24 +101,31 @@ func (src *systemdMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch
 		}
 	}()
 
-	summary := summarizeUnits(allUnits)
-	src.collectSummaryMetrics(summary, gather, now)
+	src.collectWithMetrics("summary", gather, now, func() {
+		summary := summarizeUnits(allUnits)
+		src.collectSummaryMetrics(summary, gather, now)
+	})
 
 	units := src.filterUnits(allUnits)
+	cache := newUnitPropertyCache(conn)
 
 	var wg sync.WaitGroup
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		src.collectUnitStatusMetrics(conn, units, gather, now)
+		src.collectWithMetrics("status", gather, now, func() {
+			src.collectUnitStatusMetrics(cache, units, gather, now)
+		})
 	}()
 
 	if src.collectStartTimeMetrics {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			src.collectUnitStartTimeMetrics(conn, units, gather, now)
+			src.collectWithMetrics("start_time", gather, now, func() {
+				src.collectUnitStartTimeMetrics(cache, units, gather, now)
+			})
 		}()
 	}
 
@@ -119,29 +133,64 @@ func (src *systemdMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			src.collectUnitTasksMetrics(conn, units, gather, now)
+			src.collectWithMetrics("tasks", gather, now, func() {
+				src.collectUnitTasksMetrics(cache, units, gather, now)
+			})
 		}()
 	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		src.collectTimers(conn, units, gather, now)
+		src.collectWithMetrics("timers", gather, now, func() {
+			src.collectTimers(cache, units, gather, now)
+		})
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		src.collectSockets(conn, units, gather, now)
+		src.collectWithMetrics("sockets", gather, now, func() {
+			src.collectSockets(cache, units, gather, now)
+		})
 	}()
 
-	err = src.collectSystemState(conn, gather, now)
-	if err != nil {
-		glog.Errorf("error collecting system stats: %v", err)
+	if src.collectCGroupMetrics {
+		cgroupUnits := src.filterUnitsForCGroup(allUnits)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src.collectWithMetrics("cgroup", gather, now, func() {
+				src.collectUnitCGroupMetrics(cache, cgroupUnits, gather, now)
+			})
+		}()
+	}
+
+	if src.collectJournalMetrics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src.collectWithMetrics("journal", gather, now, func() {
+				src.collectJournal(gather, now)
+			})
+		}()
 	}
 
+	src.collectWithMetrics("system_state", gather, now, func() {
+		if stateErr := src.collectSystemState(conn, gather, now); stateErr != nil {
+			glog.Errorf("error collecting system stats: %v", stateErr)
+		}
+	})
+
 	// wait for collection to complete and then close the gathering channel
 	wg.Wait()
+
+	if src.eventWatcher != nil {
+		for _, point := range src.eventWatcher.drain() {
+			gather <- point
+		}
+	}
+	gather <- src.metricPoint("scrape_duration_seconds", time.Since(scrapeStart).Seconds(), now, nil)
 	close(gather)
 
 	// wait for gathering to process all the points
@@ -153,22 +202,42 @@ func (src *systemdMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch
 	return result, err
 }
 
-func (src *systemdMetricsSource) collectUnitStatusMetrics(conn *dbus.Conn, units []unit, ch chan<- *MetricPoint, now int64) {
+// collectWithMetrics runs collect, timing it and recovering any panic so a single failing
+// sub-collector can't take down the whole scrape. It emits a duration and a success (1/0)
+// point tagged with collector=name, so operators can see which sub-collector is slow or
+// failing on a given node.
+func (src *systemdMetricsSource) collectWithMetrics(name string, ch chan<- *MetricPoint, now int64, collect func()) {
+	start := time.Now()
+	success := 1.0
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Errorf("panic in systemd %s collector: %v", name, r)
+			success = 0.0
+		}
+		tags := map[string]string{}
+		setTag(tags, "collector", name)
+		ch <- src.metricPoint("collector_duration_seconds", time.Since(start).Seconds(), now, tags)
+		ch <- src.metricPoint("collector_success", success, now, tags)
+	}()
+	collect()
+}
+
+func (src *systemdMetricsSource) collectUnitStatusMetrics(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
 	for _, unit := range units {
 		serviceType := ""
 		if strings.HasSuffix(unit.Name, ".service") {
-			serviceTypeProperty, err := conn.GetUnitTypeProperty(unit.Name, "Service", "Type")
+			props, err := cache.typed(unit.Name, "Service")
 			if err != nil {
-				glog.Infof("couldn't get unit '%s' Type: %s", unit.Name, err)
-			} else {
-				serviceType = serviceTypeProperty.Value.Value().(string)
+				glog.Infof("couldn't get unit '%s' Service properties: %s", unit.Name, err)
+			} else if t, ok := props["Type"].(string); ok {
+				serviceType = t
 			}
 		} else if strings.HasSuffix(unit.Name, ".mount") {
-			serviceTypeProperty, err := conn.GetUnitTypeProperty(unit.Name, "Mount", "Type")
+			props, err := cache.typed(unit.Name, "Mount")
 			if err != nil {
-				glog.V(4).Infof("couldn't get unit '%s' Type: %s", unit.Name, err)
-			} else {
-				serviceType = serviceTypeProperty.Value.Value().(string)
+				glog.V(4).Infof("couldn't get unit '%s' Mount properties: %s", unit.Name, err)
+			} else if t, ok := props["Type"].(string); ok {
+				serviceType = t
 			}
 		}
 		for _, stateName := range unitStatesName {
@@ -181,63 +250,59 @@ func (src *systemdMetricsSource) collectUnitStatusMetrics(conn *dbus.Conn, units
 			ch <- src.metricPoint("unit_state", isActive, now, tags)
 		}
 		if src.collectRestartMetrics && strings.HasSuffix(unit.Name, ".service") {
-			// NRestarts wasn't added until systemd 235.
-			restartsCount, err := conn.GetUnitTypeProperty(unit.Name, "Service", "NRestarts")
+			props, err := cache.typed(unit.Name, "Service")
 			if err != nil {
-				glog.V(4).Infof("couldn't get unit '%s' NRestarts: %s", unit.Name, err)
-			} else {
+				glog.V(4).Infof("couldn't get unit '%s' Service properties: %s", unit.Name, err)
+			} else if restarts, ok := props["NRestarts"].(uint32); ok {
+				// NRestarts wasn't added until systemd 235.
 				tags := map[string]string{}
 				setTag(tags, "name", unit.Name)
-				ch <- src.metricPoint("service_restart_total", float64(restartsCount.Value.Value().(uint32)), now, tags)
+				ch <- src.metricPoint("service_restart_total", float64(restarts), now, tags)
 			}
 		}
 	}
 }
 
-func (src *systemdMetricsSource) collectSockets(conn *dbus.Conn, units []unit, ch chan<- *MetricPoint, now int64) {
+func (src *systemdMetricsSource) collectSockets(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
 	for _, unit := range units {
 		if !strings.HasSuffix(unit.Name, ".socket") {
 			continue
 		}
 
-		acceptedConnectionCount, err := conn.GetUnitTypeProperty(unit.Name, "Socket", "NAccepted")
+		props, err := cache.typed(unit.Name, "Socket")
 		if err != nil {
-			glog.V(4).Infof("couldn't get unit '%s' NAccepted: %s", unit.Name, err)
+			glog.V(4).Infof("couldn't get unit '%s' Socket properties: %s", unit.Name, err)
 			continue
 		}
+
 		tags := map[string]string{}
 		setTag(tags, "name", unit.Name)
-		ch <- src.metricPoint("socket_accepted_connections_total", float64(acceptedConnectionCount.Value.Value().(uint32)), now, tags)
 
-		currentConnectionCount, err := conn.GetUnitTypeProperty(unit.Name, "Socket", "NConnections")
-		if err != nil {
-			glog.V(4).Infof("couldn't get unit '%s' NConnections: %s", unit.Name, err)
-			continue
+		if accepted, ok := props["NAccepted"].(uint32); ok {
+			ch <- src.metricPoint("socket_accepted_connections_total", float64(accepted), now, tags)
+		}
+		if current, ok := props["NConnections"].(uint32); ok {
+			ch <- src.metricPoint("socket_current_connections", float64(current), now, tags)
 		}
-		ch <- src.metricPoint("socket_current_connections", float64(currentConnectionCount.Value.Value().(uint32)), now, tags)
-
 		// NRefused wasn't added until systemd 239.
-		refusedConnectionCount, err := conn.GetUnitTypeProperty(unit.Name, "Socket", "NRefused")
-		if err != nil {
-			glog.V(4).Infof("couldn't get unit '%s' NRefused: %s", unit.Name, err)
-		} else {
-			ch <- src.metricPoint("socket_refused_connections_total", float64(refusedConnectionCount.Value.Value().(uint32)), now, tags)
+		if refused, ok := props["NRefused"].(uint32); ok {
+			ch <- src.metricPoint("socket_refused_connections_total", float64(refused), now, tags)
 		}
 	}
 }
 
-func (src *systemdMetricsSource) collectUnitStartTimeMetrics(conn *dbus.Conn, units []unit, ch chan<- *MetricPoint, now int64) {
-	var startTimeUsec uint64
+func (src *systemdMetricsSource) collectUnitStartTimeMetrics(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
 	for _, unit := range units {
-		if unit.ActiveState != "active" {
-			startTimeUsec = 0
-		} else {
-			timestampValue, err := conn.GetUnitProperty(unit.Name, "ActiveEnterTimestamp")
+		var startTimeUsec uint64
+		if unit.ActiveState == "active" {
+			props, err := cache.generic(unit.Name)
 			if err != nil {
-				glog.V(4).Infof("couldn't get unit '%s' StartTimeUsec: %s", unit.Name, err)
+				glog.V(4).Infof("couldn't get unit '%s' properties: %s", unit.Name, err)
 				continue
 			}
-			startTimeUsec = timestampValue.Value.Value().(uint64)
+			if val, ok := props["ActiveEnterTimestamp"].(uint64); ok {
+				startTimeUsec = val
+			}
 		}
 		tags := map[string]string{}
 		setTag(tags, "name", unit.Name)
@@ -245,52 +310,50 @@ func (src *systemdMetricsSource) collectUnitStartTimeMetrics(conn *dbus.Conn, un
 	}
 }
 
-func (src *systemdMetricsSource) collectUnitTasksMetrics(conn *dbus.Conn, units []unit, ch chan<- *MetricPoint, now int64) {
-	var val uint64
+func (src *systemdMetricsSource) collectUnitTasksMetrics(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
 	for _, unit := range units {
-		if strings.HasSuffix(unit.Name, ".service") {
-			tasksCurrentCount, err := conn.GetUnitTypeProperty(unit.Name, "Service", "TasksCurrent")
-			if err != nil {
-				glog.Infof("couldn't get unit '%s' TasksCurrent: %s", unit.Name, err)
-			} else {
-				val = tasksCurrentCount.Value.Value().(uint64)
-				// Don't set if tasksCurrent if dbus reports MaxUint64.
-				if val != math.MaxUint64 {
-					tags := map[string]string{}
-					setTag(tags, "name", unit.Name)
-					ch <- src.metricPoint("unit_tasks_current", float64(val), now, tags)
-				}
-			}
-			tasksMaxCount, err := conn.GetUnitTypeProperty(unit.Name, "Service", "TasksMax")
-			if err != nil {
-				glog.Infof("couldn't get unit '%s' TasksMax: %s", unit.Name, err)
-			} else {
-				val = tasksMaxCount.Value.Value().(uint64)
-				// Don't set if tasksMax if dbus reports MaxUint64.
-				if val != math.MaxUint64 {
-					tags := map[string]string{}
-					setTag(tags, "name", unit.Name)
-					ch <- src.metricPoint("unit_tasks_max", float64(val), now, tags)
-				}
-			}
+		if !strings.HasSuffix(unit.Name, ".service") {
+			continue
+		}
+
+		props, err := cache.typed(unit.Name, "Service")
+		if err != nil {
+			glog.Infof("couldn't get unit '%s' Service properties: %s", unit.Name, err)
+			continue
+		}
+
+		// Don't set tasksCurrent/tasksMax if dbus reports MaxUint64 (unset).
+		if val, ok := props["TasksCurrent"].(uint64); ok && val != math.MaxUint64 {
+			tags := map[string]string{}
+			setTag(tags, "name", unit.Name)
+			ch <- src.metricPoint("unit_tasks_current", float64(val), now, tags)
+		}
+		if val, ok := props["TasksMax"].(uint64); ok && val != math.MaxUint64 {
+			tags := map[string]string{}
+			setTag(tags, "name", unit.Name)
+			ch <- src.metricPoint("unit_tasks_max", float64(val), now, tags)
 		}
 	}
 }
 
-func (src *systemdMetricsSource) collectTimers(conn *dbus.Conn, units []unit, ch chan<- *MetricPoint, now int64) {
+func (src *systemdMetricsSource) collectTimers(cache *unitPropertyCache, units []unit, ch chan<- *MetricPoint, now int64) {
 	for _, unit := range units {
 		if !strings.HasSuffix(unit.Name, ".timer") {
 			continue
 		}
 
-		lastTriggerValue, err := conn.GetUnitTypeProperty(unit.Name, "Timer", "LastTriggerUSec")
+		props, err := cache.typed(unit.Name, "Timer")
 		if err != nil {
-			glog.V(4).Infof("couldn't get unit '%s' LastTriggerUSec: %s", unit.Name, err)
+			glog.V(4).Infof("couldn't get unit '%s' Timer properties: %s", unit.Name, err)
+			continue
+		}
+		val, ok := props["LastTriggerUSec"].(uint64)
+		if !ok {
 			continue
 		}
 		tags := map[string]string{}
 		setTag(tags, "name", unit.Name)
-		ch <- src.metricPoint("timer_last_trigger_seconds", float64(lastTriggerValue.Value.Value().(uint64))/1e6, now, tags)
+		ch <- src.metricPoint("timer_last_trigger_seconds", float64(val)/1e6, now, tags)
 	}
 }
 
@@ -348,6 +411,21 @@ func (src *systemdMetricsSource) filterUnits(units []unit) []unit {
 	return filtered
 }
 
+// filterUnitsForCGroup is like filterUnits but force-allows .slice units through the default
+// unitExclude (which hides .device/.scope/.slice to keep the general-purpose collectors cheap).
+// collectUnitCGroupMetrics is specifically about .slice aggregate attribution, so applying the
+// default there would silently report nothing for it; an operator-supplied unitInclude or
+// unitExclude is still honored.
+func (src *systemdMetricsSource) filterUnitsForCGroup(units []unit) []unit {
+	filtered := make([]unit, 0, len(units))
+	for _, unit := range units {
+		if (src.unitsFilter == nil || src.unitsFilter.matchForCGroup(unit.Name)) && unit.LoadState == "loaded" {
+			filtered = append(filtered, unit)
+		}
+	}
+	return filtered
+}
+
 func (src *systemdMetricsSource) filterAppend(slice []*MetricPoint, point *MetricPoint) []*MetricPoint {
 	if src.filters == nil || src.filters.Match(point.Metric, point.Tags) {
 		return append(slice, point)
@@ -446,20 +524,82 @@ func NewProvider(uri *url.URL) (MetricsSourceProvider, error) {
 		}
 	}
 
+	collectCGroupMetrics := false
+	if len(vals["cgroupMetrics"]) > 0 {
+		var err error
+		collectCGroupMetrics, err = strconv.ParseBool(vals["cgroupMetrics"][0])
+		if err != nil {
+			glog.Infof("error parsing cgroupMetrics property: %v", err)
+		}
+	}
+
+	collectJournalMetrics := false
+	if len(vals["journalMetrics"]) > 0 {
+		var err error
+		collectJournalMetrics, err = strconv.ParseBool(vals["journalMetrics"][0])
+		if err != nil {
+			glog.Infof("error parsing journalMetrics property: %v", err)
+		}
+	}
+
+	journalWindow := 60 * time.Second
+	if len(vals["journalWindow"]) > 0 {
+		parsed, err := time.ParseDuration(vals["journalWindow"][0])
+		if err != nil {
+			glog.Infof("error parsing journalWindow property: %v", err)
+		} else {
+			journalWindow = parsed
+		}
+	}
+
+	var journalUnits *regexp.Regexp
+	if len(vals["journalUnits"]) > 0 {
+		parsed, err := regexp.Compile(vals["journalUnits"][0])
+		if err != nil {
+			glog.Infof("error parsing journalUnits property: %v", err)
+		} else {
+			journalUnits = parsed
+		}
+	}
+
+	eventMode := false
+	if len(vals["eventMode"]) > 0 {
+		var err error
+		eventMode, err = strconv.ParseBool(vals["eventMode"][0])
+		if err != nil {
+			glog.Infof("error parsing eventMode property: %v", err)
+		}
+	}
+
 	unitsFilter := fromQuery(vals)
 	filters := filter.FromQuery(vals)
 
-	sources := make([]MetricsSource, 1)
-	sources[0] = &systemdMetricsSource{
+	metricsSource := &systemdMetricsSource{
 		prefix:                  prefix,
 		source:                  source,
 		collectTaskMetrics:      collectTaskMetrics,
 		collectStartTimeMetrics: collectStartTimeMetrics,
 		collectRestartMetrics:   collectRestartMetrics,
+		collectCGroupMetrics:    collectCGroupMetrics,
+		collectJournalMetrics:   collectJournalMetrics,
+		journalWindow:           journalWindow,
+		journalUnits:            journalUnits,
 		unitsFilter:             unitsFilter,
 		filters:                 filters,
 	}
 
+	if eventMode {
+		watcher := newUnitEventWatcher(metricsSource)
+		if err := watcher.start(); err != nil {
+			glog.Errorf("couldn't start systemd event watcher: %v", err)
+		} else {
+			metricsSource.eventWatcher = watcher
+		}
+	}
+
+	sources := make([]MetricsSource, 1)
+	sources[0] = metricsSource
+
 	return &systemdProvider{
 		sources: sources,
 	}, nil
@@ -0,0 +1,134 @@
+package systemd
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// defaultUnitExclude mirrors node_exporter's default systemd unit exclusion: units that are
+// rarely interesting and numerous enough (especially transient scopes) to dominate a scrape.
+const defaultUnitExclude = `.+\.(device|scope|slice)`
+
+// unitFilter is a pair of precompiled include/exclude regexes applied to unit names before any
+// per-unit dbus calls are made. A unit is collected when it matches include (if set) and does
+// not match exclude.
+type unitFilter struct {
+	include        *regexp.Regexp
+	exclude        *regexp.Regexp
+	excludeDefault bool // exclude was defaulted, not set explicitly via unitExclude
+}
+
+func (f *unitFilter) match(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// matchForCGroup is like match, but force-allows .slice units through the default exclude.
+// cgroup metrics are specifically about reporting on .slice aggregates, so the default that
+// hides .slice/.scope/.device units (sized for the general-purpose collectors) would otherwise
+// defeat this collector entirely. An operator-supplied unitExclude is still honored.
+func (f *unitFilter) matchForCGroup(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		if !(f.excludeDefault && strings.HasSuffix(name, ".slice")) {
+			return false
+		}
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// fromQuery builds a unitFilter from the unitInclude/unitExclude query params, falling back to
+// defaultUnitExclude when unitExclude is not given. For backward compatibility, the older
+// unitsFilter param (a glob, e.g. "docker.service,kube*") is translated into an equivalent
+// include regex when unitInclude is not also set.
+func fromQuery(vals url.Values) *unitFilter {
+	f := &unitFilter{}
+
+	include := ""
+	if len(vals["unitInclude"]) > 0 {
+		include = vals["unitInclude"][0]
+	} else if len(vals["unitsFilter"]) > 0 {
+		include = globToRegex(vals["unitsFilter"][0])
+	}
+	if include != "" {
+		compiled, err := regexp.Compile(include)
+		if err != nil {
+			glog.Errorf("error compiling unit include filter %q: %v", include, err)
+		} else {
+			f.include = compiled
+		}
+	}
+
+	exclude := defaultUnitExclude
+	f.excludeDefault = len(vals["unitExclude"]) == 0
+	if !f.excludeDefault {
+		exclude = vals["unitExclude"][0]
+	}
+	if exclude != "" {
+		compiled, err := regexp.Compile(anchor(exclude))
+		if err != nil {
+			glog.Errorf("error compiling unit exclude filter %q: %v", exclude, err)
+		} else {
+			f.exclude = compiled
+		}
+	}
+
+	if f.include == nil && f.exclude == nil {
+		return nil
+	}
+	return f
+}
+
+// anchor wraps a regex so it must match the whole unit name, matching node_exporter semantics.
+func anchor(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+// globToRegex translates the legacy comma-separated glob syntax (e.g. "docker.service,kube*")
+// into an equivalent anchored alternation regex.
+func globToRegex(globs string) string {
+	parts := strings.Split(globs, ",")
+	patterns := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		patterns = append(patterns, globPatternToRegex(part))
+	}
+	if len(patterns) == 0 {
+		return ""
+	}
+	return strings.Join(patterns, "|")
+}
+
+func globPatternToRegex(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return "^" + b.String() + "$"
+}